@@ -1,6 +1,8 @@
 package main
 
 import (
+	"sync"
+
 	"github.com/golemcloud/golem-go/std"
 
 	// Import this for using the common lib:
@@ -12,12 +14,23 @@ func init() {
 	binding.SetExportsPackNameExportsComponentNameApi(&Impl{})
 }
 
+// initStdOnce makes std.Init idempotent: every exported method calls
+// ensureStdInit, but the underlying os/net-http wrappers only need to be
+// installed once per component instance.
+var initStdOnce sync.Once
+
+func ensureStdInit() {
+	initStdOnce.Do(func() {
+		std.Init(std.Packages{Os: true, NetHttp: true})
+	})
+}
+
 type Impl struct {
 	counter uint64
 }
 
 func (i *Impl) Add(value uint64) {
-	std.Init(std.Packages{Os: true, NetHttp: true})
+	ensureStdInit()
 
 	// Example common lib usage
 	// fmt.Println(lib.ExampleCommonFunction())
@@ -26,7 +39,7 @@ func (i *Impl) Add(value uint64) {
 }
 
 func (i *Impl) Get() uint64 {
-	std.Init(std.Packages{Os: true, NetHttp: true})
+	ensureStdInit()
 
 	return i.counter
 }
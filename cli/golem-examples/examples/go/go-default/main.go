@@ -1,6 +1,8 @@
 package main
 
 import (
+	"sync"
+
 	"github.com/golemcloud/golem-go/std"
 
 	"pack/name/binding"
@@ -21,19 +23,30 @@ func init() {
 // total State can be stored in global variables
 var total uint64
 
+// initStdOnce makes std.Init idempotent: every exported method calls
+// ensureStdInit, but the underlying os/net-http wrappers only need to be
+// installed once per component instance.
+var initStdOnce sync.Once
+
+func ensureStdInit() {
+	initStdOnce.Do(func() {
+		std.Init(std.Packages{Os: true, NetHttp: true})
+	})
+}
+
 type ComponentNameImpl struct {
 }
 
 // Implementation of the exported interface
 
 func (e *ComponentNameImpl) Add(value uint64) {
-	std.Init(std.Packages{Os: true, NetHttp: true})
+	ensureStdInit()
 
 	total += value
 }
 
 func (e *ComponentNameImpl) Get() uint64 {
-	std.Init(std.Packages{Os: true, NetHttp: true})
+	ensureStdInit()
 
 	return total
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"sync"
+
 	"github.com/golemcloud/golem-go/std"
 
 	// Import this for using the common lib:
@@ -15,8 +17,19 @@ func init() {
 
 var counter uint64
 
+// initStdOnce makes std.Init idempotent: every exported function calls
+// ensureStdInit, but the underlying os/net-http wrappers only need to be
+// installed once per component instance.
+var initStdOnce sync.Once
+
+func ensureStdInit() {
+	initStdOnce.Do(func() {
+		std.Init(std.Packages{Os: true, NetHttp: true})
+	})
+}
+
 func Add(value uint64) {
-	std.Init(std.Packages{Os: true, NetHttp: true})
+	ensureStdInit()
 
 	// Example common lib usage
 	// fmt.Println(lib.ExampleCommonFunction())
@@ -25,7 +38,7 @@ func Add(value uint64) {
 }
 
 func Get() uint64 {
-	std.Init(std.Packages{Os: true, NetHttp: true})
+	ensureStdInit()
 
 	return counter
 }
@@ -0,0 +1,41 @@
+package wasihttp
+
+import (
+	"io"
+
+	"github.com/golemcloud/golem-go/binding/wasi/http/types"
+)
+
+// bodyReader adapts a wasi:http incoming-body's input-stream into an
+// io.ReadCloser so it can be used as an *http.Request's Body.
+type bodyReader struct {
+	stream types.InputStream
+	body   types.IncomingBody
+	closed bool
+}
+
+func newBodyReader(stream types.InputStream, body types.IncomingBody) io.ReadCloser {
+	return &bodyReader{stream: stream, body: body}
+}
+
+func (r *bodyReader) Read(p []byte) (int, error) {
+	chunk, err, isErr := r.stream.BlockingRead(uint64(len(p))).Result()
+	if isErr {
+		if err.Closed() {
+			return 0, io.EOF
+		}
+		return 0, errIncomingBody
+	}
+	n := copy(p, chunk.Slice())
+	return n, nil
+}
+
+func (r *bodyReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.stream.ResourceDrop()
+	r.body.ResourceDrop()
+	return nil
+}
@@ -0,0 +1,23 @@
+package wasihttp
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	errIncomingBody  = errors.New("wasihttp: failed to read incoming request body")
+	errUnknownMethod = errors.New("wasihttp: unknown request method")
+)
+
+// errOutgoingHeaders and errOutgoingBody wrap the cause of a failure while
+// building the outgoing response (a wasi:http binding-level error, or the
+// status code that was rejected) so it can flow through writeError alongside
+// ordinary Go errors.
+func errOutgoingHeaders(cause any) error {
+	return fmt.Errorf("wasihttp: failed to build response headers: %v", cause)
+}
+
+func errOutgoingBody(cause any) error {
+	return fmt.Errorf("wasihttp: failed to write response body: %v", cause)
+}
@@ -0,0 +1,30 @@
+package wasihttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// WriteJSON marshals v, sets Content-Type: application/json and writes
+// status and the encoded body to w. It's the common response shape so
+// handlers don't each hand-roll json.Marshal and error handling.
+func WriteJSON(w http.ResponseWriter, status int, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// DecodeJSON reads r.Body and unmarshals it into v.
+func DecodeJSON(r *http.Request, v any) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,80 @@
+package wasihttp
+
+import (
+	"bytes"
+	"net/http"
+
+	incominghandler "app/components-go/component-name/binding/wasi/http/incoming-handler"
+
+	"github.com/golemcloud/golem-go/binding/wasi/http/types"
+	"go.bytecodealliance.org/cm"
+)
+
+// responseWriter implements http.ResponseWriter by buffering the status,
+// headers and body in memory, then translating them into a single
+// wasi:http outgoing-response when flush is called. wasi:http requires the
+// status code and headers up front, so they can't be streamed out as the
+// handler writes incrementally.
+type responseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newResponseWriter() *responseWriter {
+	return &responseWriter{header: http.Header{}, statusCode: http.StatusOK}
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	return w.body.Write(p)
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+}
+
+// flush sends the buffered status, headers and body through responseOut.
+func (w *responseWriter) flush(responseOut incominghandler.ResponseOutparam) {
+	var headerKeyValues []cm.Tuple[types.FieldKey, types.FieldValue]
+	for key, values := range w.header {
+		for _, value := range values {
+			headerKeyValues = append(headerKeyValues, cm.Tuple[types.FieldKey, types.FieldValue]{
+				F0: types.FieldKey(key),
+				F1: types.FieldValue(cm.ToList([]byte(value))),
+			})
+		}
+	}
+	headers, err, isErr := types.FieldsFromList(cm.ToList(headerKeyValues)).Result()
+	if isErr {
+		writeError(responseOut, http.StatusInternalServerError, errOutgoingHeaders(err))
+		return
+	}
+
+	response := types.NewOutgoingResponse(headers)
+	if response.SetStatusCode(types.StatusCode(w.statusCode)) == cm.ResultErr {
+		writeError(responseOut, http.StatusInternalServerError, errOutgoingHeaders(w.statusCode))
+		return
+	}
+
+	body, err2, isErr := response.Body().Result()
+	if isErr {
+		writeError(responseOut, http.StatusInternalServerError, errOutgoingBody(err2))
+		return
+	}
+	stream, err3, isErr := body.Write().Result()
+	if isErr {
+		writeError(responseOut, http.StatusInternalServerError, errOutgoingBody(err3))
+		return
+	}
+	if _, err4, isErr := stream.BlockingWriteAndFlush(cm.ToList(w.body.Bytes())).Result(); isErr {
+		writeError(responseOut, http.StatusInternalServerError, errOutgoingBody(err4))
+		return
+	}
+
+	result := cm.OK[cm.Result[types.ErrorCodeShape, types.OutgoingResponse, types.ErrorCode]](response)
+	types.ResponseOutparamSet(types.ResponseOutparam(responseOut), result)
+}
@@ -0,0 +1,205 @@
+// Package wasihttp adapts the wasi:http incoming-handler export to the
+// standard net/http interfaces, so a component can register handlers with
+// http.Handler/http.ResponseWriter instead of hand-building
+// incominghandler.ResponseOutparam plumbing for every request.
+//
+// A component calls HandleFunc (or Handle) from init() and then installs
+// Serve as its incominghandler.Exports.Handle:
+//
+//	func init() {
+//		wasihttp.HandleFunc("GET /users/{id}", getUser)
+//		incominghandler.Exports.Handle = wasihttp.Serve
+//	}
+package wasihttp
+
+import (
+	"net/http"
+
+	incominghandler "app/components-go/component-name/binding/wasi/http/incoming-handler"
+
+	"github.com/golemcloud/golem-go/binding/wasi/http/types"
+	"go.bytecodealliance.org/cm"
+)
+
+// defaultMux is the router used by the package-level HandleFunc/Handle/Use
+// helpers and by Serve. Components that want more than one router can build
+// their own with NewRouter instead.
+var defaultMux = NewRouter()
+
+// HandleFunc registers handler for pattern on the default router. pattern
+// follows http.ServeMux syntax ("GET /users/{id}", "/health", ...); path
+// parameters are read back in the handler with PathValue(r, "id").
+func HandleFunc(pattern string, handler http.HandlerFunc) {
+	defaultMux.HandleFunc(pattern, handler)
+}
+
+// Handle registers handler for pattern on the default router.
+func Handle(pattern string, handler http.Handler) {
+	defaultMux.Handle(pattern, handler)
+}
+
+// Use appends middleware to the default router's chain. Middleware is
+// applied in registration order, outermost first.
+func Use(mw ...Middleware) {
+	defaultMux.Use(mw...)
+}
+
+// Serve is the incominghandler.Exports.Handle implementation: it decodes
+// request into an *http.Request, dispatches it through the default router,
+// and flushes the result back through responseOut.
+func Serve(request incominghandler.IncomingRequest, responseOut incominghandler.ResponseOutparam) {
+	serve(defaultMux, request, responseOut)
+}
+
+// Router dispatches http.Request to a registered http.Handler by method and
+// path, supporting path parameters ("/users/{id}") the way http.ServeMux
+// does, plus a middleware chain applied to every request.
+type Router struct {
+	mux        *http.ServeMux
+	middleware []Middleware
+}
+
+// Middleware wraps a handler to run logic before and/or after it, such as
+// logging, panic recovery, CORS headers or gzip compression.
+type Middleware func(http.Handler) http.Handler
+
+// NewRouter returns an empty Router.
+func NewRouter() *Router {
+	return &Router{mux: http.NewServeMux()}
+}
+
+// HandleFunc registers handler for pattern.
+func (rt *Router) HandleFunc(pattern string, handler http.HandlerFunc) {
+	rt.mux.HandleFunc(pattern, handler)
+}
+
+// Handle registers handler for pattern.
+func (rt *Router) Handle(pattern string, handler http.Handler) {
+	rt.mux.Handle(pattern, handler)
+}
+
+// Use appends middleware to the router's chain, applied outermost first.
+func (rt *Router) Use(mw ...Middleware) {
+	rt.middleware = append(rt.middleware, mw...)
+}
+
+// PathValue returns the value of a path parameter previously captured by a
+// pattern such as "/users/{id}", mirroring (*http.Request).PathValue.
+func PathValue(r *http.Request, name string) string {
+	return r.PathValue(name)
+}
+
+func (rt *Router) handler() http.Handler {
+	var h http.Handler = rt.mux
+	for i := len(rt.middleware) - 1; i >= 0; i-- {
+		h = rt.middleware[i](h)
+	}
+	return h
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.handler().ServeHTTP(w, r)
+}
+
+// serve decodes request, runs it through mux, and writes the response back
+// through responseOut. Errors that happen before a handler could run (a
+// malformed incoming request) are reported as a 500 response rather than
+// panicking, since the component is still expected to answer responseOut.
+func serve(mux *Router, request incominghandler.IncomingRequest, responseOut incominghandler.ResponseOutparam) {
+	req, err := decodeRequest(request)
+	if err != nil {
+		writeError(responseOut, http.StatusInternalServerError, err)
+		return
+	}
+	defer req.Body.Close()
+
+	rw := newResponseWriter()
+	mux.ServeHTTP(rw, req)
+	rw.flush(responseOut)
+}
+
+// decodeRequest turns a wasi:http incoming-request into a standard
+// *http.Request, with Body backed by bodyReader over the incoming body's
+// input-stream.
+func decodeRequest(request incominghandler.IncomingRequest) (*http.Request, error) {
+	method, err := decodeMethod(request.Method())
+	if err != nil {
+		return nil, err
+	}
+
+	path := "/"
+	if pathWithQuery := request.PathWithQuery(); pathWithQuery.Some() != nil {
+		path = *pathWithQuery.Some()
+	}
+
+	header := http.Header{}
+	for _, entry := range request.Headers().Entries().Slice() {
+		key := http.CanonicalHeaderKey(string(entry.F0))
+		header[key] = append(header[key], string(entry.F1.Slice()))
+	}
+
+	bodyResult := request.Consume()
+	if bodyResult.IsErr() {
+		return nil, errIncomingBody
+	}
+	body := *bodyResult.OK()
+	streamResult := body.Stream()
+	if streamResult.IsErr() {
+		return nil, errIncomingBody
+	}
+
+	httpReq, err := http.NewRequest(method, path, newBodyReader(*streamResult.OK(), body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header = header
+	return httpReq, nil
+}
+
+func decodeMethod(method types.Method) (string, error) {
+	switch {
+	case method.Get():
+		return http.MethodGet, nil
+	case method.Head():
+		return http.MethodHead, nil
+	case method.Post():
+		return http.MethodPost, nil
+	case method.Put():
+		return http.MethodPut, nil
+	case method.Delete():
+		return http.MethodDelete, nil
+	case method.Connect():
+		return http.MethodConnect, nil
+	case method.Options():
+		return http.MethodOptions, nil
+	case method.Trace():
+		return http.MethodTrace, nil
+	case method.Patch():
+		return http.MethodPatch, nil
+	default:
+		if other := method.Other(); other != nil {
+			return *other, nil
+		}
+		return "", errUnknownMethod
+	}
+}
+
+// writeError answers responseOut with a bare status code and message,
+// for failures that happen before a handler (and its own error handling)
+// ever runs.
+func writeError(responseOut incominghandler.ResponseOutparam, status int, cause error) {
+	headers := types.NewFields()
+	response := types.NewOutgoingResponse(headers)
+	response.SetStatusCode(types.StatusCode(status))
+
+	if bodyResult := response.Body(); !bodyResult.IsErr() {
+		body := *bodyResult.OK()
+		if streamResult := body.Write(); !streamResult.IsErr() {
+			stream := *streamResult.OK()
+			stream.BlockingWriteAndFlush(cm.ToList([]byte(cause.Error())))
+		}
+	}
+
+	result := cm.OK[cm.Result[types.ErrorCodeShape, types.OutgoingResponse, types.ErrorCode]](response)
+	types.ResponseOutparamSet(types.ResponseOutparam(responseOut), result)
+}
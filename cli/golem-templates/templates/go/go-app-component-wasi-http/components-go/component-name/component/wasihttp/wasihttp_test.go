@@ -0,0 +1,70 @@
+package wasihttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterPathMatching(t *testing.T) {
+	rt := NewRouter()
+	rt.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user:" + PathValue(r, "id")))
+	})
+	rt.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	cases := []struct {
+		method, path, want string
+		wantStatus         int
+	}{
+		{http.MethodGet, "/users/42", "user:42", http.StatusOK},
+		{http.MethodGet, "/health", "ok", http.StatusOK},
+		{http.MethodGet, "/missing", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, req)
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s %s: status = %d, want %d", c.method, c.path, rec.Code, c.wantStatus)
+		}
+		if c.want != "" && rec.Body.String() != c.want {
+			t.Errorf("%s %s: body = %q, want %q", c.method, c.path, rec.Body.String(), c.want)
+		}
+	}
+}
+
+func TestRouterMiddlewareOrder(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name+":before")
+				next.ServeHTTP(w, r)
+				order = append(order, name+":after")
+			})
+		}
+	}
+
+	rt := NewRouter()
+	rt.Use(mw("outer"), mw("inner"))
+	rt.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rt.ServeHTTP(httptest.NewRecorder(), req)
+
+	want := []string{"outer:before", "inner:before", "handler", "inner:after", "outer:after"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("order = %v, want %v", order, want)
+			break
+		}
+	}
+}
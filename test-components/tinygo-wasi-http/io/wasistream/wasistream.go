@@ -0,0 +1,281 @@
+// Package wasistream adapts wasi:io input-stream and output-stream handles
+// to the standard io.Reader/io.Writer family, so callers can use io.Copy,
+// deadlines, and context cancellation the way they would over a net.Conn
+// instead of hand-rolling fixed-size blocking loops around BlockingRead and
+// Write.
+package wasistream
+
+import (
+	"context"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	go_wasi_http "golem.com/tinygo_wasi/tinygo_wasi"
+)
+
+// defaultChunk is the size of the buffer WriteTo and ReadFrom allocate for
+// each copy; io.Copy's own buffer is not used on those paths.
+const defaultChunk = 32 * 1024
+
+// Reader adapts a wasi:io/streams input-stream into an io.Reader,
+// io.WriterTo and io.Closer. Reads block until data is available, the
+// stream closes, SetReadDeadline elapses, or the context passed to
+// ReadContext is canceled.
+type Reader struct {
+	mu       sync.Mutex
+	stream   go_wasi_http.WasiHttp0_2_0_TypesInputStream
+	deadline time.Time
+	closed   bool
+}
+
+// NewReader wraps stream and arranges for it to be dropped by the garbage
+// collector if Close is never called.
+func NewReader(stream go_wasi_http.WasiHttp0_2_0_TypesInputStream) *Reader {
+	r := &Reader{stream: stream}
+	runtime.SetFinalizer(r, func(r *Reader) { r.Close() })
+	return r
+}
+
+// SetReadDeadline sets the deadline for future Read and WriteTo calls, in
+// the style of net.Conn.SetReadDeadline. A zero value disables the
+// deadline.
+func (r *Reader) SetReadDeadline(t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deadline = t
+	return nil
+}
+
+// Read implements io.Reader using the background context; see ReadContext.
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.ReadContext(context.Background(), p)
+}
+
+// ReadContext is like Read but additionally unblocks when ctx is done. It
+// first attempts a non-blocking Stream.Read so a ready stream never pays
+// for a pollable subscription, and only falls back to blocking when no data
+// is immediately available.
+func (r *Reader) ReadContext(ctx context.Context, p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	result := r.stream.Read(uint64(len(p)))
+	if result.IsErr() {
+		if result.UnwrapErr() == go_wasi_http.WasiIo0_2_0_StreamsStreamErrorClosed() {
+			return 0, io.EOF
+		}
+		return 0, result.UnwrapErr()
+	}
+	if chunk := result.Unwrap(); len(chunk) > 0 {
+		copy(p, chunk)
+		return len(chunk), nil
+	}
+
+	if err := r.block(ctx, r.readDeadline()); err != nil {
+		return 0, err
+	}
+	return r.ReadContext(ctx, p)
+}
+
+func (r *Reader) readDeadline() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deadline
+}
+
+// block waits for pollable to fire, racing it against ctx cancellation and
+// deadline in the style of the netstack deadlineTimer: a timer that, once
+// it fires, causes the blocking wait to be abandoned.
+func block(pollable interface{ Block() }, ctx context.Context, deadline time.Time) error {
+	if ctx.Done() == nil && deadline.IsZero() {
+		pollable.Block()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	go func() {
+		pollable.Block()
+		close(ready)
+	}()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ready:
+		return nil
+	case <-timeout:
+		return os.ErrDeadlineExceeded
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Reader) block(ctx context.Context, deadline time.Time) error {
+	pollable := r.stream.Subscribe()
+	defer pollable.Drop()
+	return block(pollable, ctx, deadline)
+}
+
+// WriteTo implements io.WriterTo, copying until the stream closes or an
+// error occurs. Callers doing io.Copy(dst, reader) get this fast path
+// instead of io.Copy's own fixed-size buffer loop.
+func (r *Reader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	buf := make([]byte, defaultChunk)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			wn, werr := w.Write(buf[:n])
+			total += int64(wn)
+			if werr != nil {
+				return total, werr
+			}
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Close drops the underlying stream handle. It is idempotent, so it is safe
+// to call explicitly and then again from the GC finalizer.
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	runtime.SetFinalizer(r, nil)
+	r.stream.Drop()
+	return nil
+}
+
+// Writer adapts a wasi:io/streams output-stream into an io.Writer,
+// io.ReaderFrom and io.Closer, using CheckWrite to respect the stream's
+// backpressure instead of writing fixed-size chunks blind to flow control.
+type Writer struct {
+	mu       sync.Mutex
+	stream   go_wasi_http.WasiHttp0_2_0_TypesOutputStream
+	deadline time.Time
+	closed   bool
+}
+
+// NewWriter wraps stream and arranges for it to be dropped by the garbage
+// collector if Close is never called.
+func NewWriter(stream go_wasi_http.WasiHttp0_2_0_TypesOutputStream) *Writer {
+	w := &Writer{stream: stream}
+	runtime.SetFinalizer(w, func(w *Writer) { w.Close() })
+	return w
+}
+
+// SetWriteDeadline sets the deadline for future Write and ReadFrom calls, in
+// the style of net.Conn.SetWriteDeadline. A zero value disables the
+// deadline.
+func (w *Writer) SetWriteDeadline(t time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.deadline = t
+	return nil
+}
+
+// Write implements io.Writer using the background context; see
+// WriteContext.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.WriteContext(context.Background(), p)
+}
+
+// WriteContext is like Write but additionally unblocks when ctx is done. It
+// calls CheckWrite before every chunk so a full output buffer suspends this
+// call rather than the whole component.
+func (w *Writer) WriteContext(ctx context.Context, p []byte) (int, error) {
+	var written int
+	for written < len(p) {
+		n, err := w.writeChunk(ctx, p[written:])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (w *Writer) writeChunk(ctx context.Context, p []byte) (int, error) {
+	checkResult := w.stream.CheckWrite()
+	if checkResult.IsErr() {
+		return 0, checkResult.UnwrapErr()
+	}
+
+	ready := checkResult.Unwrap()
+	if ready == 0 {
+		w.mu.Lock()
+		deadline := w.deadline
+		w.mu.Unlock()
+
+		pollable := w.stream.Subscribe()
+		defer pollable.Drop()
+		if err := block(pollable, ctx, deadline); err != nil {
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	chunk := p
+	if uint64(len(chunk)) > ready {
+		chunk = chunk[:ready]
+	}
+	result := w.stream.Write(chunk)
+	if result.IsErr() {
+		return 0, result.UnwrapErr()
+	}
+	return len(chunk), nil
+}
+
+// ReadFrom implements io.ReaderFrom, giving callers doing io.Copy(writer,
+// src) backpressure-aware writes instead of a fixed 1KB loop.
+func (w *Writer) ReadFrom(src io.Reader) (int64, error) {
+	var total int64
+	buf := make([]byte, defaultChunk)
+	for {
+		n, rerr := src.Read(buf)
+		if n > 0 {
+			wn, werr := w.Write(buf[:n])
+			total += int64(wn)
+			if werr != nil {
+				return total, werr
+			}
+		}
+		if rerr == io.EOF {
+			return total, nil
+		}
+		if rerr != nil {
+			return total, rerr
+		}
+	}
+}
+
+// Close drops the underlying stream handle. It is idempotent, so it is safe
+// to call explicitly and then again from the GC finalizer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	runtime.SetFinalizer(w, nil)
+	w.stream.Drop()
+	return nil
+}
@@ -0,0 +1,60 @@
+package wasistream
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+type fakePollable struct {
+	blockAfter time.Duration
+	blocked    chan struct{}
+}
+
+func (p *fakePollable) Block() {
+	if p.blocked != nil {
+		close(p.blocked)
+	}
+	time.Sleep(p.blockAfter)
+}
+
+func TestBlockReturnsWhenPollableFires(t *testing.T) {
+	err := block(&fakePollable{}, context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("block() error = %v, want nil", err)
+	}
+}
+
+func TestBlockDeadlineExceeded(t *testing.T) {
+	p := &fakePollable{blockAfter: time.Hour}
+	err := block(p, context.Background(), time.Now().Add(10*time.Millisecond))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("block() error = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+}
+
+func TestBlockContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := &fakePollable{blockAfter: time.Hour}
+	err := block(p, ctx, time.Time{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("block() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestBlockNoDeadlineNoContextBlocksSynchronously(t *testing.T) {
+	p := &fakePollable{blocked: make(chan struct{})}
+	err := block(p, context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("block() error = %v, want nil", err)
+	}
+	select {
+	case <-p.blocked:
+	default:
+		t.Fatal("block() returned without calling pollable.Block()")
+	}
+}
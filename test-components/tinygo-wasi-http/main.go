@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sync"
 
 	tinygowasihttp "golem.com/tinygo_wasi_http/binding/golem/it/tinygo-wasi-http"
 
@@ -17,6 +18,20 @@ func init() {
 	tinygowasihttp.Exports.Example1 = Example1
 }
 
+// initStdOnce makes std.Init idempotent: Example1 calls ensureStdInit on
+// every invocation, but the underlying os/net-http wrappers only need to be
+// installed once per component instance.
+var initStdOnce sync.Once
+
+func ensureStdInit() {
+	initStdOnce.Do(func() {
+		std.Init(std.Packages{
+			Os:      true,
+			NetHttp: true,
+		})
+	})
+}
+
 type ExampleRequest struct {
 	Name     string
 	Amount   uint32
@@ -29,10 +44,7 @@ type ExampleResponse struct {
 }
 
 func Example1(_ string) string {
-	std.Init(std.Packages{
-		Os:      true,
-		NetHttp: true,
-	})
+	ensureStdInit()
 
 	port := os.Getenv("PORT")
 
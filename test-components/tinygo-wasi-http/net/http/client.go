@@ -6,12 +6,12 @@ import (
 	"net/url"
 	"strings"
 
-	"github.com/golemcloud/golem-go/net/http"
+	"golem.com/tinygo_wasi_http/roundtrip"
 )
 
 // Using a custom client until this https://github.com/golemcloud/golem/issues/709 is resolved
 
-var transport stdhttp.RoundTripper = &http.WasiHttpTransport{}
+var transport stdhttp.RoundTripper = &roundtrip.WasiHttpTransport{}
 
 type Client struct {
 	stdhttp.Client
@@ -67,3 +67,15 @@ func (c *Client) Head(url string) (resp *stdhttp.Response, err error) {
 	}
 	return c.Do(req)
 }
+
+// DoAll issues reqs concurrently and returns their results in the same
+// order as reqs, instead of sending them one at a time like Do.
+func DoAll(reqs []*stdhttp.Request) []roundtrip.Result {
+	return DefaultClient.DoAll(reqs)
+}
+
+// DoAll issues reqs concurrently and returns their results in the same
+// order as reqs, instead of sending them one at a time like Do.
+func (c *Client) DoAll(reqs []*stdhttp.Request) []roundtrip.Result {
+	return roundtrip.DoConcurrent(reqs)
+}
@@ -0,0 +1,146 @@
+package roundtrip
+
+import (
+	"net/http"
+
+	go_wasi_http "golem.com/tinygo_wasi/tinygo_wasi"
+)
+
+// Result is the outcome of one request issued through DoConcurrent,
+// DoConcurrentStream or Client.DoAll.
+type Result struct {
+	Request  *http.Request
+	Response *http.Response
+	Err      error
+}
+
+// inFlight tracks a single outgoing request from send until its response
+// (or error) has been produced.
+type inFlight struct {
+	request       *http.Request
+	requestHandle go_wasi_http.WasiHttp0_2_0_TypesOutgoingRequest
+	future        go_wasi_http.WasiHttp0_2_0_OutgoingHandlerFutureIncomingResponse
+	pollable      go_wasi_http.WasiIo0_2_0_PollPollable
+}
+
+// DoConcurrent issues all reqs at once through the wasi:http
+// outgoing-handler and returns once every one of them has completed (or
+// failed), in the same order as reqs. Internally it drives all of the
+// in-flight futures off of a single wasi:io/poll.poll call instead of
+// blocking on one future at a time (as GetIncomingResponse does), so the
+// requests genuinely progress in parallel within this worker - without
+// spawning any goroutines, since the blocking poll host call suspends the
+// whole component regardless of which goroutine calls it.
+func DoConcurrent(reqs []*http.Request) []Result {
+	return reorderResults(reqs, DoConcurrentStream(reqs))
+}
+
+// reorderResults collects every Result off results (which may complete in
+// any order) and returns them in the same order as reqs.
+func reorderResults(reqs []*http.Request, results <-chan Result) []Result {
+	byRequest := make(map[*http.Request]Result, len(reqs))
+	for result := range results {
+		byRequest[result.Request] = result
+	}
+
+	reordered := make([]Result, len(reqs))
+	for i, req := range reqs {
+		reordered[i] = byRequest[req]
+	}
+	return reordered
+}
+
+// DoConcurrentStream is like DoConcurrent but returns results in completion
+// order rather than input order. The wasi:io/poll.poll loop that drives all
+// in-flight requests runs synchronously on the calling goroutine - by the
+// time DoConcurrentStream returns, every request has already completed and
+// the channel is fully populated and closed, ready to be ranged over.
+func DoConcurrentStream(reqs []*http.Request) <-chan Result {
+	out := make(chan Result, len(reqs))
+
+	pending := make([]*inFlight, 0, len(reqs))
+	for _, req := range reqs {
+		flight, err := startRequest(req)
+		if err != nil {
+			out <- Result{Request: req, Err: err}
+			continue
+		}
+		pending = append(pending, flight)
+	}
+
+	drive(pending, out)
+
+	return out
+}
+
+// startRequest builds and dispatches a single outgoing request, returning a
+// handle to poll on rather than blocking for the response.
+func startRequest(request *http.Request) (*inFlight, error) {
+	opts, _ := RequestOptionsFromContext(request.Context())
+
+	requestHandle, err := buildOutgoingRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	future, err := sendRequest(requestHandle, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inFlight{
+		request:       request,
+		requestHandle: requestHandle,
+		future:        future,
+		pollable:      future.Subscribe(),
+	}, nil
+}
+
+// drive polls every still-pending request until all of them have a ready
+// response, emitting a Result for each as soon as it completes. On a
+// partial failure it still drops only the handles belonging to the request
+// that failed; every other request keeps being polled to completion.
+func drive(pending []*inFlight, out chan<- Result) {
+	defer close(out)
+
+	for len(pending) > 0 {
+		pollables := make([]go_wasi_http.WasiIo0_2_0_PollPollable, len(pending))
+		for i, flight := range pending {
+			pollables[i] = flight.pollable
+		}
+		ready := go_wasi_http.WasiIo0_2_0_PollPoll(pollables)
+
+		readyIndexes := make(map[uint32]bool, len(ready))
+		for _, idx := range ready {
+			readyIndexes[idx] = true
+		}
+
+		remaining := pending[:0]
+		for i, flight := range pending {
+			if !readyIndexes[uint32(i)] {
+				remaining = append(remaining, flight)
+				continue
+			}
+			out <- resolve(flight)
+		}
+		pending = remaining
+	}
+}
+
+// resolve drops the now-ready future's pollable, reads its response and
+// decodes it. If reading the response fails, the future and request handles
+// are dropped here. On success they belong to the response body and are
+// dropped when it is closed. If decoding fails, the handles are not dropped.
+func resolve(flight *inFlight) Result {
+	flight.pollable.Drop()
+
+	incomingResponse, err := GetIncomingResponse(flight.future)
+	if err != nil {
+		flight.future.Drop()
+		flight.requestHandle.Drop()
+		return Result{Request: flight.request, Err: err}
+	}
+
+	response, err := decodeResponse(flight.request, flight.requestHandle, incomingResponse, flight.future)
+	return Result{Request: flight.request, Response: response, Err: err}
+}
@@ -0,0 +1,45 @@
+package roundtrip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReorderResultsRestoresInputOrder(t *testing.T) {
+	reqA := httptest.NewRequest(http.MethodGet, "http://example.com/a", nil)
+	reqB := httptest.NewRequest(http.MethodGet, "http://example.com/b", nil)
+	reqC := httptest.NewRequest(http.MethodGet, "http://example.com/c", nil)
+	reqs := []*http.Request{reqA, reqB, reqC}
+
+	results := make(chan Result, len(reqs))
+	// Completed out of input order, as DoConcurrentStream would emit them.
+	results <- Result{Request: reqC}
+	results <- Result{Request: reqA}
+	results <- Result{Request: reqB}
+	close(results)
+
+	got := reorderResults(reqs, results)
+	if len(got) != len(reqs) {
+		t.Fatalf("reorderResults() returned %d results, want %d", len(got), len(reqs))
+	}
+	for i, req := range reqs {
+		if got[i].Request != req {
+			t.Errorf("reorderResults()[%d].Request = %v, want %v", i, got[i].Request, req)
+		}
+	}
+}
+
+func TestReorderResultsMissingEntryIsZeroValue(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/a", nil)
+	results := make(chan Result)
+	close(results)
+
+	got := reorderResults([]*http.Request{req}, results)
+	if len(got) != 1 {
+		t.Fatalf("reorderResults() returned %d results, want 1", len(got))
+	}
+	if got[0].Request != nil || got[0].Response != nil || got[0].Err != nil {
+		t.Errorf("reorderResults()[0] = %+v, want zero value", got[0])
+	}
+}
@@ -0,0 +1,200 @@
+package roundtrip
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how WasiHttpTransport retries a failed request.
+//
+// A request is retried when its round trip returns any error or a response
+// whose status is one of RetryableStatusCodes. A retry re-sends the request;
+// the replay cache only holds the final response of an idempotent request,
+// so it does not stop the host from seeing the retried attempts.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// A value <= 1 disables retries.
+	MaxAttempts int
+
+	// Backoff returns how long to wait after the given failed attempt
+	// (1-based), i.e. Backoff(1) is the delay before attempt 2. If nil,
+	// DefaultBackoff is used.
+	Backoff func(attempt int) time.Duration
+
+	// RetryableStatusCodes lists response status codes that should be
+	// retried. 502, 503 and 504 are retried by DefaultRetryPolicy.
+	RetryableStatusCodes []int
+}
+
+// DefaultBackoff implements a simple exponential backoff starting at 100ms
+// and capped at 2s.
+func DefaultBackoff(attempt int) time.Duration {
+	delay := 100 * time.Millisecond
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay > 2*time.Second {
+			return 2 * time.Second
+		}
+	}
+	return delay
+}
+
+// DefaultRetryPolicy retries up to 3 attempts on network errors and on
+// 502/503/504 responses, with DefaultBackoff between attempts.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:          3,
+		Backoff:              DefaultBackoff,
+		RetryableStatusCodes: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	}
+}
+
+func (p *RetryPolicy) isRetryableStatus(status int) bool {
+	if p == nil {
+		return false
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	if p == nil || p.Backoff == nil {
+		return DefaultBackoff(attempt)
+	}
+	return p.Backoff(attempt)
+}
+
+// RequestOptions carries per-request durability and timeout settings that
+// the transport cannot infer from an *http.Request alone. It is attached to
+// a request via WithRequestOptions and read back with
+// RequestOptionsFromContext.
+type RequestOptions struct {
+	// ConnectTimeout bounds how long establishing the connection may take.
+	// Zero means no timeout.
+	ConnectTimeout time.Duration
+
+	// FirstByteTimeout bounds how long waiting for the first response byte
+	// may take. Zero means no timeout.
+	FirstByteTimeout time.Duration
+
+	// BetweenBytesTimeout bounds the gap allowed between two consecutive
+	// reads of the response body. Zero means no timeout.
+	BetweenBytesTimeout time.Duration
+
+	// Idempotent tags the request with an Idempotency-Key header derived
+	// from the request's method, URL and body, and serves a cached response
+	// for a repeat of it. Retries follow Retry whether or not it is set.
+	// Nil falls back to the transport's IdempotencyMode; set it
+	// explicitly to override that default for this request in either
+	// direction.
+	Idempotent *bool
+
+	// Retry overrides the transport's RetryPolicy for this request. Nil
+	// falls back to the transport's policy.
+	Retry *RetryPolicy
+}
+
+type requestOptionsContextKey struct{}
+
+// WithRequestOptions returns a copy of ctx carrying opts, to be read back by
+// WasiHttpTransport.RoundTrip via the request's context.
+func WithRequestOptions(ctx context.Context, opts RequestOptions) context.Context {
+	return context.WithValue(ctx, requestOptionsContextKey{}, opts)
+}
+
+// RequestOptionsFromContext extracts RequestOptions previously attached with
+// WithRequestOptions, if any.
+func RequestOptionsFromContext(ctx context.Context) (RequestOptions, bool) {
+	opts, ok := ctx.Value(requestOptionsContextKey{}).(RequestOptions)
+	return opts, ok
+}
+
+// idempotencyKey derives a stable Idempotency-Key for method+URL+body so a
+// crash-recovery replay of the same outgoing call doesn't double-post. The
+// body is buffered in memory to hash it; callers that set Idempotent on very
+// large request bodies should consider disabling it for that request.
+func idempotencyKey(request *http.Request) (string, io.ReadCloser, error) {
+	h := sha256.New()
+	h.Write([]byte(request.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(request.URL.String()))
+
+	if request.Body == nil {
+		return hex.EncodeToString(h.Sum(nil)), nil, nil
+	}
+
+	body, err := io.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return "", nil, err
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), io.NopCloser(bytes.NewReader(body)), nil
+}
+
+// cachedResponse is a replay-safe snapshot of a completed HTTP response: the
+// bytes are captured once so that re-executing a Golem worker up to an
+// already-completed HTTP call returns exactly the same response without
+// re-hitting the network.
+type cachedResponse struct {
+	status     string
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+func (c *cachedResponse) toResponse(request *http.Request) *http.Response {
+	header := make(http.Header, len(c.header))
+	for k, v := range c.header {
+		header[k] = append([]string(nil), v...)
+	}
+	return &http.Response{
+		Status:        c.status,
+		StatusCode:    c.statusCode,
+		Header:        header,
+		ContentLength: int64(len(c.body)),
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		Request:       request,
+	}
+}
+
+// replayCache stores completed responses keyed by idempotency key, for the
+// lifetime of the transport. It is safe for concurrent use.
+type replayCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+func newReplayCache() *replayCache {
+	return &replayCache{entries: make(map[string]*cachedResponse)}
+}
+
+func (c *replayCache) get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *replayCache) put(key string, entry *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+}
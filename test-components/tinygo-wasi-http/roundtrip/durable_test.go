@@ -0,0 +1,150 @@
+package roundtrip
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultBackoff(t *testing.T) {
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{10, 2 * time.Second},
+	}
+	for _, c := range cases {
+		if got := DefaultBackoff(c.attempt); got != c.want {
+			t.Errorf("DefaultBackoff(%d) = %v, want %v", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestRetryPolicyNilIsSingleAttempt(t *testing.T) {
+	var p *RetryPolicy
+	if got := p.maxAttempts(); got != 1 {
+		t.Errorf("nil RetryPolicy.maxAttempts() = %d, want 1", got)
+	}
+	if p.isRetryableStatus(http.StatusBadGateway) {
+		t.Error("nil RetryPolicy.isRetryableStatus() = true, want false")
+	}
+	if got := p.backoff(3); got != DefaultBackoff(3) {
+		t.Errorf("nil RetryPolicy.backoff(3) = %v, want %v", got, DefaultBackoff(3))
+	}
+}
+
+func TestDefaultRetryPolicyRetryableStatus(t *testing.T) {
+	p := DefaultRetryPolicy()
+	for _, code := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		if !p.isRetryableStatus(code) {
+			t.Errorf("DefaultRetryPolicy().isRetryableStatus(%d) = false, want true", code)
+		}
+	}
+	if p.isRetryableStatus(http.StatusOK) {
+		t.Error("DefaultRetryPolicy().isRetryableStatus(200) = true, want false")
+	}
+	if p.maxAttempts() != 3 {
+		t.Errorf("DefaultRetryPolicy().maxAttempts() = %d, want 3", p.maxAttempts())
+	}
+}
+
+func TestIdempotencyKeyStableForSameRequest(t *testing.T) {
+	newRequest := func() *http.Request {
+		return httptest.NewRequest(http.MethodPost, "http://example.com/things", strings.NewReader("payload"))
+	}
+
+	key1, body1, err := idempotencyKey(newRequest())
+	if err != nil {
+		t.Fatalf("idempotencyKey() error = %v", err)
+	}
+	got1, err := io.ReadAll(body1)
+	if err != nil {
+		t.Fatalf("reading restored body: %v", err)
+	}
+	if string(got1) != "payload" {
+		t.Errorf("restored body = %q, want %q", got1, "payload")
+	}
+
+	key2, _, err := idempotencyKey(newRequest())
+	if err != nil {
+		t.Fatalf("idempotencyKey() error = %v", err)
+	}
+	if key1 != key2 {
+		t.Errorf("idempotencyKey() not stable across identical requests: %q != %q", key1, key2)
+	}
+
+	req3 := httptest.NewRequest(http.MethodPost, "http://example.com/things", strings.NewReader("different"))
+	key3, _, err := idempotencyKey(req3)
+	if err != nil {
+		t.Fatalf("idempotencyKey() error = %v", err)
+	}
+	if key3 == key1 {
+		t.Error("idempotencyKey() returned the same key for requests with different bodies")
+	}
+}
+
+func TestIdempotencyKeyNilBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/things", nil)
+	if err != nil {
+		t.Fatalf("http.NewRequest() error = %v", err)
+	}
+	key, body, err := idempotencyKey(req)
+	if err != nil {
+		t.Fatalf("idempotencyKey() error = %v", err)
+	}
+	if key == "" {
+		t.Error("idempotencyKey() returned an empty key")
+	}
+	if body != nil {
+		t.Error("idempotencyKey() returned a non-nil body for a request with no body")
+	}
+}
+
+func TestCachedResponseToResponse(t *testing.T) {
+	entry := &cachedResponse{
+		status:     "201 Created",
+		statusCode: http.StatusCreated,
+		header:     http.Header{"X-Test": []string{"1"}},
+		body:       []byte("hello"),
+	}
+	request := httptest.NewRequest(http.MethodGet, "http://example.com/things", nil)
+
+	resp := entry.toResponse(request)
+	if resp.Status != "201 Created" || resp.StatusCode != http.StatusCreated {
+		t.Errorf("toResponse() status = %q/%d, want %q/%d", resp.Status, resp.StatusCode, "201 Created", http.StatusCreated)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response body: %v", err)
+	}
+	if string(body) != "hello" {
+		t.Errorf("toResponse() body = %q, want %q", body, "hello")
+	}
+
+	// Mutating the returned header must not affect the cache entry.
+	resp.Header.Set("X-Test", "2")
+	if entry.header.Get("X-Test") != "1" {
+		t.Error("toResponse() returned a header that aliases the cache entry")
+	}
+}
+
+func TestReplayCache(t *testing.T) {
+	c := newReplayCache()
+	if _, ok := c.get("missing"); ok {
+		t.Fatal("get() on empty cache returned ok = true")
+	}
+
+	entry := &cachedResponse{status: "200 OK", statusCode: http.StatusOK}
+	c.put("key", entry)
+
+	got, ok := c.get("key")
+	if !ok || got != entry {
+		t.Errorf("get() = %v, %v, want %v, true", got, ok, entry)
+	}
+}
@@ -1,21 +1,179 @@
 package roundtrip
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	go_wasi_http "golem.com/tinygo_wasi/tinygo_wasi"
+
+	"golem.com/tinygo_wasi_http/io/wasistream"
 )
 
+// WasiHttpTransport is an http.RoundTripper backed by wasi:http. Beyond the
+// plain request/response translation, it understands Golem's replay-based
+// execution model: requests can be marked idempotent (IdempotencyMode), in
+// which case completed responses are cached for the lifetime of the
+// transport and replayed instead of re-issued, and failed requests are
+// retried according to Retry.
 type WasiHttpTransport struct {
+	// Retry configures retry behaviour for requests. Nil disables retries
+	// (a single attempt is made).
+	Retry *RetryPolicy
+
+	// IdempotencyMode, when true, makes every request through this
+	// transport idempotent by default: an Idempotency-Key header derived
+	// from method+URL+body is attached, and completed responses are
+	// replayed from cache rather than re-sent. Override per request with
+	// RequestOptions.Idempotent via WithRequestOptions.
+	IdempotencyMode bool
+
+	cacheOnce sync.Once
+	cache     *replayCache
+}
+
+func (t *WasiHttpTransport) replayCache() *replayCache {
+	t.cacheOnce.Do(func() {
+		t.cache = newReplayCache()
+	})
+	return t.cache
+}
+
+func (t *WasiHttpTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	opts, hasOpts := RequestOptionsFromContext(request.Context())
+
+	idempotent := t.IdempotencyMode
+	retry := t.Retry
+	if hasOpts {
+		if opts.Idempotent != nil {
+			idempotent = *opts.Idempotent
+		}
+		if opts.Retry != nil {
+			retry = opts.Retry
+		}
+	}
+
+	var key string
+	if idempotent {
+		var (
+			bodyForRetry io.ReadCloser
+			err          error
+		)
+		key, bodyForRetry, err = idempotencyKey(request)
+		if err != nil {
+			return nil, err
+		}
+		request.Body = bodyForRetry
+		request.Header.Set("Idempotency-Key", key)
+
+		if cached, ok := t.replayCache().get(key); ok {
+			return cached.toResponse(request), nil
+		}
+	}
+
+	// Buffer the body once so it can be replayed across retry attempts:
+	// wasi:io streams, like net/http bodies in general, are consumed by a
+	// single read pass.
+	var bodyBytes []byte
+	if request.Body != nil && retry.maxAttempts() > 1 {
+		var err error
+		bodyBytes, err = io.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	}
+
+	var (
+		response *http.Response
+		err      error
+	)
+	for attempt := 1; attempt <= retry.maxAttempts(); attempt++ {
+		if attempt > 1 && bodyBytes != nil {
+			request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		}
+		response, err = t.roundTripOnce(request, opts)
+		if err == nil && !retry.isRetryableStatus(response.StatusCode) {
+			break
+		}
+		if attempt == retry.maxAttempts() {
+			break
+		}
+		if err == nil {
+			// This attempt's response is being discarded in favor of a
+			// retry: close it now rather than leaving its WasiStreamReader
+			// (and the wasi handles it holds) for the GC finalizer, which
+			// may never run before the component is torn down.
+			response.Body.Close()
+		}
+		time.Sleep(retry.backoff(attempt))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if idempotent {
+		if err := t.cacheResponse(key, response); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// cacheResponse drains and stores the response body so a future replay of
+// this idempotent request can be served without re-hitting the network, then
+// hands the caller back an equivalent response reading from the captured
+// bytes. If the body cannot be read, nothing is cached and the error is
+// returned.
+func (t *WasiHttpTransport) cacheResponse(key string, response *http.Response) error {
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return err
+	}
+	entry := &cachedResponse{
+		status:     response.Status,
+		statusCode: response.StatusCode,
+		header:     response.Header,
+		body:       body,
+	}
+	t.replayCache().put(key, entry)
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
 }
 
-func (t WasiHttpTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+func (t *WasiHttpTransport) roundTripOnce(request *http.Request, opts RequestOptions) (*http.Response, error) {
+	requestHandle, err := buildOutgoingRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	future, err := sendRequest(requestHandle, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	incomingResponse, err := GetIncomingResponse(future)
+	if err != nil {
+		return nil, err
+	}
 
+	return decodeResponse(request, requestHandle, incomingResponse, future)
+}
+
+// buildOutgoingRequest translates request into a wasi:http outgoing-request,
+// including writing its body. The returned handle still needs to be passed
+// to sendRequest.
+func buildOutgoingRequest(request *http.Request) (go_wasi_http.WasiHttp0_2_0_TypesOutgoingRequest, error) {
 	var headerKeyValues []go_wasi_http.WasiHttp0_2_0_TypesTuple2FieldKeyFieldValueT
 	for key, values := range request.Header {
 		for _, value := range values {
@@ -91,57 +249,60 @@ func (t WasiHttpTransport) RoundTrip(request *http.Request) (*http.Response, err
 
 		requestBodyResult := requestHandle.Body()
 		if requestBodyResult.IsErr() {
-			return nil, errors.New("Failed to get request body")
+			return requestHandle, errors.New("Failed to get request body")
 		}
 		requestBody := requestBodyResult.Unwrap()
 
 		requestStreamResult := requestBody.Write()
 		if requestStreamResult.IsErr() {
-			return nil, errors.New("Failed to start writing request body")
+			return requestHandle, errors.New("Failed to start writing request body")
 		}
-		requestStream := requestStreamResult.Unwrap()
-
-		buffer := make([]byte, 1024)
-		for {
-			n, err := reader.Read(buffer)
-
-			result := requestStream.Write(buffer[:n])
-			if result.IsErr() {
-				requestStream.Drop()
-				requestBody.Drop()
-				return nil, errors.New("Failed to write request body chunk")
-			}
-
-			if err == io.EOF {
-				break
-			}
+		requestStream := wasistream.NewWriter(requestStreamResult.Unwrap())
+
+		// io.Copy uses the body's WriteTo if it has one and otherwise
+		// requestStream.ReadFrom; either way every chunk goes through
+		// requestStream.Write, which waits on CheckWrite backpressure.
+		if _, err := io.Copy(requestStream, reader); err != nil {
+			requestStream.Close()
+			requestBody.Drop()
+			return requestHandle, fmt.Errorf("failed to write request body: %w", err)
 		}
 
-		requestStream.Drop()
+		requestStream.Close()
 		go_wasi_http.StaticOutgoingBodyFinish(requestBody, go_wasi_http.None[go_wasi_http.WasiHttp0_2_0_TypesTrailers]())
 		// requestBody.Drop() // TODO: this fails with "unknown handle index 0"
 	}
 
-	// TODO: timeouts
-	connectTimeoutNanos := go_wasi_http.None[uint64]()
-	firstByteTimeoutNanos := go_wasi_http.None[uint64]()
-	betweenBytesTimeoutNanos := go_wasi_http.None[uint64]()
+	return requestHandle, nil
+}
+
+// sendRequest dispatches requestHandle through the wasi:http
+// outgoing-handler and returns a future for its response. The caller is
+// responsible for eventually resolving it, e.g. via GetIncomingResponse.
+func sendRequest(requestHandle go_wasi_http.WasiHttp0_2_0_TypesOutgoingRequest, opts RequestOptions) (go_wasi_http.WasiHttp0_2_0_OutgoingHandlerFutureIncomingResponse, error) {
 	options := go_wasi_http.NewRequestOptions()
-	options.SetConnectTimeout(connectTimeoutNanos)
-	options.SetFirstByteTimeout(firstByteTimeoutNanos)
-	options.SetBetweenBytesTimeout(betweenBytesTimeoutNanos)
+	options.SetConnectTimeout(durationToNanos(opts.ConnectTimeout))
+	options.SetFirstByteTimeout(durationToNanos(opts.FirstByteTimeout))
+	options.SetBetweenBytesTimeout(durationToNanos(opts.BetweenBytesTimeout))
 
 	futureResult := go_wasi_http.WasiHttp0_2_0_OutgoingHandlerHandle(requestHandle, go_wasi_http.Some(options))
 	if futureResult.IsErr() {
-		return nil, errors.New("Failed to send request")
-	}
-	future := futureResult.Unwrap()
-
-	incomingResponse, err := GetIncomingResponse(future)
-	if err != nil {
-		return nil, err
+		var zero go_wasi_http.WasiHttp0_2_0_OutgoingHandlerFutureIncomingResponse
+		return zero, errors.New("Failed to send request")
 	}
+	return futureResult.Unwrap(), nil
+}
 
+// decodeResponse translates a resolved incoming-response, together with the
+// handles that produced it, into an *http.Response whose Body streams the
+// response via wasistream and, on Close, drops the body stream together with
+// the incoming-body, incoming-response, future and outgoing-request handles.
+func decodeResponse(
+	request *http.Request,
+	requestHandle go_wasi_http.WasiHttp0_2_0_TypesOutgoingRequest,
+	incomingResponse go_wasi_http.WasiHttp0_2_0_TypesIncomingResponse,
+	future go_wasi_http.WasiHttp0_2_0_OutgoingHandlerFutureIncomingResponse,
+) (*http.Response, error) {
 	status := incomingResponse.Status()
 	responseHeaders := incomingResponse.Headers()
 	defer responseHeaders.Drop()
@@ -185,13 +346,16 @@ func (t WasiHttpTransport) RoundTrip(request *http.Request) (*http.Response, err
 	}
 	responseBodyStream := responseBodyStreamResult.Unwrap()
 
-	responseReader := WasiStreamReader{
-		Stream:           responseBodyStream,
+	responseReader := &WasiStreamReader{
+		Reader:           wasistream.NewReader(responseBodyStream),
 		Body:             responseBody,
 		OutgoingRequest:  requestHandle,
 		IncomingResponse: incomingResponse,
 		Future:           future,
 	}
+	// Drop the wasi handles from a GC finalizer if the caller never calls
+	// response.Body.Close(); Close itself clears this finalizer.
+	runtime.SetFinalizer(responseReader, func(r *WasiStreamReader) { r.Close() })
 
 	response := http.Response{
 		Status:        fmt.Sprintf("%d %s", status, http.StatusText(int(status))),
@@ -205,6 +369,16 @@ func (t WasiHttpTransport) RoundTrip(request *http.Request) (*http.Response, err
 	return &response, nil
 }
 
+// durationToNanos maps a RequestOptions timeout onto the option[u64]
+// nanosecond fields of go_wasi_http.NewRequestOptions(). The Go zero value
+// (<= 0) maps to None, matching the wasi:http default of "no timeout".
+func durationToNanos(d time.Duration) go_wasi_http.Option[uint64] {
+	if d <= 0 {
+		return go_wasi_http.None[uint64]()
+	}
+	return go_wasi_http.Some(uint64(d.Nanoseconds()))
+}
+
 func GetIncomingResponse(future go_wasi_http.WasiHttp0_2_0_OutgoingHandlerFutureIncomingResponse) (go_wasi_http.WasiHttp0_2_0_TypesIncomingResponse, error) {
 	result := future.Get()
 	if result.IsSome() {
@@ -224,31 +398,30 @@ func GetIncomingResponse(future go_wasi_http.WasiHttp0_2_0_OutgoingHandlerFuture
 	}
 }
 
+// WasiStreamReader is the http.Response.Body for a wasi:http round trip. It
+// delegates Read/WriteTo/deadline handling to wasistream.Reader, and on top
+// of that drops the request/response/future handles that the stream itself
+// doesn't own.
 type WasiStreamReader struct {
-	Stream           go_wasi_http.WasiHttp0_2_0_TypesInputStream
+	*wasistream.Reader
 	Body             go_wasi_http.WasiHttp0_2_0_TypesIncomingBody
 	OutgoingRequest  go_wasi_http.WasiHttp0_2_0_TypesOutgoingRequest
 	IncomingResponse go_wasi_http.WasiHttp0_2_0_TypesIncomingResponse
 	Future           go_wasi_http.WasiHttp0_2_0_TypesFutureIncomingResponse
+	closed           bool
 }
 
-func (reader WasiStreamReader) Read(p []byte) (int, error) {
-	c := cap(p)
-	result := reader.Stream.BlockingRead(uint64(c))
-	isEof := result.IsErr() && result.UnwrapErr() == go_wasi_http.WasiIo0_2_0_StreamsStreamErrorClosed()
-	if isEof {
-		return 0, io.EOF
-	} else if result.IsErr() {
-		return 0, errors.New("Failed to read response stream")
-	} else {
-		chunk := result.Unwrap()
-		copy(p, chunk)
-		return len(chunk), nil
+// Close drops the wasi handles backing the reader. It is idempotent so it
+// can safely run twice: once from an explicit response.Body.Close() and
+// once, if that never happens, from the GC finalizer installed in
+// decodeResponse.
+func (reader *WasiStreamReader) Close() error {
+	if reader.closed {
+		return nil
 	}
-}
-
-func (reader WasiStreamReader) Close() error {
-	reader.Stream.Drop()
+	reader.closed = true
+	runtime.SetFinalizer(reader, nil)
+	reader.Reader.Close()
 	reader.Body.Drop()
 	reader.IncomingResponse.Drop()
 	reader.Future.Drop()
@@ -4,14 +4,26 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/golemcloud/golem-go/std"
 	tinygowasi "golem.com/tinygo_wasi/binding/golem/it/tinygo-wasi"
 )
 
+// initStdOnce makes std.Init idempotent: Example1 calls ensureStdInit on
+// every invocation, but the underlying os wrapper only needs to be
+// installed once per component instance.
+var initStdOnce sync.Once
+
+func ensureStdInit() {
+	initStdOnce.Do(func() {
+		std.Init(std.Packages{Os: true})
+	})
+}
+
 func Example1(s string) int32 {
-	std.Init(std.Packages{Os: true})
+	ensureStdInit()
 
 	fmt.Println(s)
 